@@ -0,0 +1,236 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/palantir/witchcraft-go-server/wrouter"
+)
+
+// OpenAPIRegistry accumulates metadata about every route registered through a Resource created with
+// NewWithOpenAPI (and any Resource derived from it via Subroute or With), and renders that metadata as an
+// OpenAPI 3.0 document. A single registry can be shared across multiple top-level Resources so that one
+// /api/openapi.json endpoint can describe all of them.
+type OpenAPIRegistry struct {
+	mu     sync.Mutex
+	routes []openAPIRoute
+}
+
+// NewOpenAPIRegistry returns an empty OpenAPIRegistry.
+func NewOpenAPIRegistry() *OpenAPIRegistry {
+	return &OpenAPIRegistry{}
+}
+
+type openAPIRoute struct {
+	ServiceName  string
+	Method       string
+	Path         string
+	EndpointName string
+	Summary      string
+	Description  string
+	Deprecated   bool
+	Tags         []string
+	RequestBody  interface{}
+	Responses    map[int]interface{}
+}
+
+func (o *OpenAPIRegistry) record(route openAPIRoute) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.routes = append(o.routes, route)
+}
+
+// Document renders the routes accumulated so far as an OpenAPI 3.0 document with the given title and version.
+func (o *OpenAPIRegistry) Document(title, version string) map[string]interface{} {
+	o.mu.Lock()
+	routes := append([]openAPIRoute{}, o.routes...)
+	o.mu.Unlock()
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		pathItem, ok := paths[route.Path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[route.Path] = pathItem
+		}
+		pathItem[strings.ToLower(route.Method)] = route.operation()
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+}
+
+// Handler returns an http.Handler that serves Document(title, version) as JSON. Mount it with Resource.Get at an
+// opt-in path such as /api/openapi.json.
+func (o *OpenAPIRegistry) Handler(title, version string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(o.Document(title, version))
+	})
+}
+
+func (route openAPIRoute) operation() map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": route.EndpointName,
+		"tags":        append([]string{route.ServiceName}, route.Tags...),
+	}
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.Description != "" {
+		op["description"] = route.Description
+	}
+	if route.Deprecated {
+		op["deprecated"] = true
+	}
+	if route.RequestBody != nil {
+		op["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": jsonSchema(route.RequestBody)},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	for code, schema := range route.Responses {
+		responses[strconv.Itoa(code)] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": jsonSchema(schema)},
+			},
+		}
+	}
+	if len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": "default response"}
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+// jsonSchema builds a minimal JSON Schema describing the Go type of v via reflection, sufficient to document the
+// shape of a request or response body without requiring callers to hand-write a schema.
+func jsonSchema(v interface{}) map[string]interface{} {
+	return jsonSchemaForType(reflect.TypeOf(v))
+}
+
+func jsonSchemaForType(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaForType(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("json")
+			if idx := strings.IndexByte(name, ','); idx >= 0 {
+				name = name[:idx]
+			}
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchemaForType(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// docParam is a wrouter.RouteParam that has no effect on routing and instead records OpenAPI metadata about the
+// route it is attached to. It is a no-op wrouter.RouteParam (implemented by wrapping the identity middleware) so
+// that Summary, Description, RequestBody, Response, Deprecated, and Tag can be passed anywhere a wrouter.RouteParam
+// is accepted without changing runtime behavior.
+type docParam struct {
+	wrouter.RouteParam
+	apply func(*openAPIRoute)
+}
+
+func newDocParam(apply func(*openAPIRoute)) wrouter.RouteParam {
+	return docParam{
+		RouteParam: wrouter.Middleware(func(next http.Handler) http.Handler { return next }),
+		apply:      apply,
+	}
+}
+
+// Summary attaches a short, human-readable summary to a route's OpenAPI operation.
+func Summary(summary string) wrouter.RouteParam {
+	return newDocParam(func(route *openAPIRoute) { route.Summary = summary })
+}
+
+// Description attaches a longer, human-readable description to a route's OpenAPI operation.
+func Description(description string) wrouter.RouteParam {
+	return newDocParam(func(route *openAPIRoute) { route.Description = description })
+}
+
+// RequestBody documents the shape of a route's request body. schema is an instance of the Go type the handler
+// expects to decode (its value is not inspected, only its type).
+func RequestBody(schema interface{}) wrouter.RouteParam {
+	return newDocParam(func(route *openAPIRoute) { route.RequestBody = schema })
+}
+
+// Response documents the shape of one possible response a route can return for the given HTTP status code.
+// schema is an instance of the Go type the handler encodes for that status (its value is not inspected, only its
+// type). Response may be passed multiple times to document multiple status codes.
+func Response(code int, schema interface{}) wrouter.RouteParam {
+	return newDocParam(func(route *openAPIRoute) {
+		if route.Responses == nil {
+			route.Responses = map[int]interface{}{}
+		}
+		route.Responses[code] = schema
+	})
+}
+
+// Deprecated marks a route as deprecated in the OpenAPI document.
+func Deprecated() wrouter.RouteParam {
+	return newDocParam(func(route *openAPIRoute) { route.Deprecated = true })
+}
+
+// Tag attaches an additional OpenAPI tag to a route, alongside the service-name tag that is always included.
+func Tag(tag string) wrouter.RouteParam {
+	return newDocParam(func(route *openAPIRoute) { route.Tags = append(route.Tags, tag) })
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type widgetDoc struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+func TestOpenAPIRegistryDocument(t *testing.T) {
+	registry := NewOpenAPIRegistry()
+	root := NewWithOpenAPI("widget-service", &fakeRouter{}, registry)
+
+	if err := root.Get(context.Background(), "getWidget", "/widgets/{id}", http.HandlerFunc(noopHandler),
+		Summary("Get a widget"),
+		Description("Fetches a single widget by id."),
+		Response(http.StatusOK, widgetDoc{}),
+		Response(http.StatusNotFound, nil),
+		Tag("widgets"),
+	); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if err := root.Post(context.Background(), "createWidget", "/widgets", http.HandlerFunc(noopHandler),
+		RequestBody(widgetDoc{}),
+		Response(http.StatusCreated, widgetDoc{}),
+		Deprecated(),
+	); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+
+	doc := registry.Document("Widget Service", "1.0.0")
+	if doc["openapi"] != "3.0.0" {
+		t.Fatalf("got openapi %v, want 3.0.0", doc["openapi"])
+	}
+	info, _ := doc["info"].(map[string]interface{})
+	if info["title"] != "Widget Service" || info["version"] != "1.0.0" {
+		t.Fatalf("got info %+v", info)
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	getItem, _ := paths["/widgets/{id}"].(map[string]interface{})
+	get, _ := getItem["get"].(map[string]interface{})
+	if get["operationId"] != "getWidget" {
+		t.Errorf("got operationId %v, want getWidget", get["operationId"])
+	}
+	if get["summary"] != "Get a widget" {
+		t.Errorf("got summary %v", get["summary"])
+	}
+	if get["description"] != "Fetches a single widget by id." {
+		t.Errorf("got description %v", get["description"])
+	}
+	tags, _ := get["tags"].([]string)
+	if len(tags) != 2 || tags[0] != "widget-service" || tags[1] != "widgets" {
+		t.Errorf("got tags %v, want [widget-service widgets]", tags)
+	}
+	responses, _ := get["responses"].(map[string]interface{})
+	if _, ok := responses["200"]; !ok {
+		t.Errorf("responses missing 200: %+v", responses)
+	}
+	if _, ok := responses["404"]; !ok {
+		t.Errorf("responses missing 404: %+v", responses)
+	}
+
+	postItem, _ := paths["/widgets"].(map[string]interface{})
+	post, _ := postItem["post"].(map[string]interface{})
+	if post["deprecated"] != true {
+		t.Errorf("got deprecated %v, want true", post["deprecated"])
+	}
+	requestBody, _ := post["requestBody"].(map[string]interface{})
+	if requestBody == nil {
+		t.Fatal("post operation missing requestBody")
+	}
+}
+
+func TestOpenAPIRegistryDocumentDefaultsToDefaultResponse(t *testing.T) {
+	registry := NewOpenAPIRegistry()
+	root := NewWithOpenAPI("widget-service", &fakeRouter{}, registry)
+
+	if err := root.Get(context.Background(), "getWidget", "/widgets", http.HandlerFunc(noopHandler)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	doc := registry.Document("Widget Service", "1.0.0")
+	paths, _ := doc["paths"].(map[string]interface{})
+	pathItem, _ := paths["/widgets"].(map[string]interface{})
+	op, _ := pathItem["get"].(map[string]interface{})
+	responses, _ := op["responses"].(map[string]interface{})
+	if _, ok := responses["default"]; !ok {
+		t.Errorf("got responses %+v, want a \"default\" entry when no Response params were given", responses)
+	}
+}
+
+func TestOpenAPIRegistryHandlerServesDocumentAsJSON(t *testing.T) {
+	registry := NewOpenAPIRegistry()
+	root := NewWithOpenAPI("widget-service", &fakeRouter{}, registry)
+	if err := root.Get(context.Background(), "getWidget", "/widgets", http.HandlerFunc(noopHandler)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	registry.Handler("Widget Service", "1.0.0").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", got)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("response body %q is not valid JSON: %v", rec.Body.String(), err)
+	}
+	if _, ok := doc["paths"]; !ok {
+		t.Errorf("decoded document missing paths: %+v", doc)
+	}
+}
+
+func TestJSONSchemaForType(t *testing.T) {
+	schema := jsonSchema(widgetDoc{})
+	if schema["type"] != "object" {
+		t.Fatalf("got type %v, want object", schema["type"])
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	idSchema, _ := properties["id"].(map[string]interface{})
+	if idSchema["type"] != "string" {
+		t.Errorf("got id schema %+v, want type string", idSchema)
+	}
+	tagsSchema, _ := properties["tags"].(map[string]interface{})
+	if tagsSchema["type"] != "array" {
+		t.Errorf("got tags schema %+v, want type array", tagsSchema)
+	}
+	items, _ := tagsSchema["items"].(map[string]interface{})
+	if items["type"] != "string" {
+		t.Errorf("got tags items %+v, want type string", items)
+	}
+
+	if got := jsonSchema(nil); got["type"] != nil {
+		t.Errorf("got schema for nil %+v, want no type", got)
+	}
+
+	ptrSchema := jsonSchema(&widgetDoc{})
+	if ptrSchema["type"] != "object" {
+		t.Errorf("got pointer schema %+v, want type object", ptrSchema)
+	}
+}
@@ -17,6 +17,8 @@ package wresource
 import (
 	"context"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/palantir/pkg/metrics"
 	werror "github.com/palantir/witchcraft-go-error"
@@ -24,9 +26,11 @@ import (
 )
 
 const (
-	ResourceTagName = "service-name"
-	MethodTagName   = "method"
-	EndpointTagName = "endpoint"
+	ResourceTagName    = "service-name"
+	MethodTagName      = "method"
+	EndpointTagName    = "endpoint"
+	SubResourceTagName = "sub-resource"
+	VersionTagName     = "version"
 )
 
 // Resource provides a way to register a collection of endpoints. All of the routes registered using Resource will be
@@ -53,6 +57,17 @@ type Resource interface {
 
 	// Delete is a shorthand for Register(endpointName, http.MethodDelete, handler, params...)
 	Delete(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Subroute returns a child Resource whose paths are prefixed with the provided prefix and whose params are
+	// appended to the params accumulated by the parent (and any of the parent's ancestors). The child's routes are
+	// tagged with the same service-name as the parent, plus a sub-resource tag derived from prefix so that metrics
+	// for a group of nested endpoints can be drilled into independently. Mutating the returned Resource (including
+	// through further calls to Subroute or With) never affects the parent.
+	Subroute(prefix string, params ...wrouter.RouteParam) Resource
+
+	// With returns a copy of this Resource with params appended to the params that will be applied to every route
+	// registered through it. The receiver is left unmodified.
+	With(params ...wrouter.RouteParam) Resource
 }
 
 func New(resourceName string, router wrouter.Router) Resource {
@@ -62,13 +77,40 @@ func New(resourceName string, router wrouter.Router) Resource {
 	}
 }
 
+// NewWithOpenAPI behaves like New, but additionally records every route registered through the returned Resource
+// (and any Resource derived from it via Subroute or With) into registry, so that registry.Document can later
+// render an OpenAPI 3.0 document describing the whole Resource tree.
+func NewWithOpenAPI(resourceName string, router wrouter.Router, registry *OpenAPIRegistry) Resource {
+	return &resourceImpl{
+		resourceName: resourceName,
+		router:       router,
+		openAPI:      registry,
+	}
+}
+
 type resourceImpl struct {
 	// the name of the resource used for metric logging.
 	resourceName string
 	router       wrouter.Router
+
+	// pathPrefix is prepended to every path registered through this Resource. Populated by Subroute.
+	pathPrefix string
+	// subResourceName is the accumulated Subroute prefix (each level's prefix with leading and trailing path
+	// separators trimmed, levels joined by /) used as the SubResourceTagName metric tag. Empty for a Resource created
+	// directly via New.
+	subResourceName string
+	// inheritedParams are applied to every route registered through this Resource in addition to the params
+	// provided at the call site. Populated by Subroute and With.
+	inheritedParams []wrouter.RouteParam
+	// openAPI, if non-nil, receives a record of every route registered through this Resource. Populated by
+	// NewWithOpenAPI and propagated by Subroute and With.
+	openAPI *OpenAPIRegistry
+	// version, if non-empty, is recorded as the VersionTagName metric tag on every route registered through this
+	// Resource. Populated by NewVersioned.
+	version string
 }
 
-func (r *resourceImpl) Register(ctx context.Context, endpointName, method, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+func (r *resourceImpl) Register(ctx context.Context, endpointName, method, p string, handler http.Handler, params ...wrouter.RouteParam) error {
 	var tags metrics.Tags
 	resourceTag, err := metrics.NewTag(ResourceTagName, r.resourceName)
 	if err != nil {
@@ -76,6 +118,22 @@ func (r *resourceImpl) Register(ctx context.Context, endpointName, method, path
 	}
 	tags = append(tags, resourceTag)
 
+	if r.subResourceName != "" {
+		subResourceTag, err := metrics.NewTag(SubResourceTagName, r.subResourceName)
+		if err != nil {
+			return werror.WrapWithContextParams(ctx, err, "failed to create metric subResourceTag")
+		}
+		tags = append(tags, subResourceTag)
+	}
+
+	if r.version != "" {
+		versionTag, err := metrics.NewTag(VersionTagName, r.version)
+		if err != nil {
+			return werror.WrapWithContextParams(ctx, err, "failed to create metric versionTag")
+		}
+		tags = append(tags, versionTag)
+	}
+
 	methodTag, err := metrics.NewTag(MethodTagName, method)
 	if err != nil {
 		return werror.WrapWithContextParams(ctx, err, "failed to create metric methodTag")
@@ -88,7 +146,25 @@ func (r *resourceImpl) Register(ctx context.Context, endpointName, method, path
 	}
 	tags = append(tags, endpointTag)
 
-	return r.router.Register(method, path, handler, append(params, wrouter.MetricTags(tags))...)
+	allParams := append(append([]wrouter.RouteParam{}, r.inheritedParams...), params...)
+	fullPath := path.Join(r.pathPrefix, p)
+
+	if r.openAPI != nil {
+		route := openAPIRoute{
+			ServiceName:  r.resourceName,
+			Method:       method,
+			Path:         fullPath,
+			EndpointName: endpointName,
+		}
+		for _, param := range allParams {
+			if dp, ok := param.(docParam); ok {
+				dp.apply(&route)
+			}
+		}
+		r.openAPI.record(route)
+	}
+
+	return r.router.Register(method, fullPath, handler, append(allParams, wrouter.MetricTags(tags))...)
 }
 
 func (r *resourceImpl) Get(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
@@ -114,3 +190,27 @@ func (r *resourceImpl) Patch(ctx context.Context, endpointName, path string, han
 func (r *resourceImpl) Delete(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
 	return r.Register(ctx, endpointName, http.MethodDelete, path, handler, params...)
 }
+
+func (r *resourceImpl) Subroute(prefix string, params ...wrouter.RouteParam) Resource {
+	return &resourceImpl{
+		resourceName:    r.resourceName,
+		router:          r.router,
+		pathPrefix:      path.Join(r.pathPrefix, prefix),
+		subResourceName: path.Join(r.subResourceName, strings.Trim(prefix, "/")),
+		inheritedParams: append(append([]wrouter.RouteParam{}, r.inheritedParams...), params...),
+		openAPI:         r.openAPI,
+		version:         r.version,
+	}
+}
+
+func (r *resourceImpl) With(params ...wrouter.RouteParam) Resource {
+	return &resourceImpl{
+		resourceName:    r.resourceName,
+		router:          r.router,
+		pathPrefix:      r.pathPrefix,
+		subResourceName: r.subResourceName,
+		inheritedParams: append(append([]wrouter.RouteParam{}, r.inheritedParams...), params...),
+		openAPI:         r.openAPI,
+		version:         r.version,
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/palantir/witchcraft-go-server/wrouter"
+)
+
+// recordedRoute is one call recorded by fakeRouter.Register, in the order it was received.
+type recordedRoute struct {
+	method string
+	path   string
+}
+
+// fakeRouter is a minimal wrouter.Router that just records every Register call, in order, so tests can walk the
+// tree of Resources produced by Subroute/With and confirm both the paths they register and the order they register
+// them in.
+type fakeRouter struct {
+	registered []recordedRoute
+}
+
+func (f *fakeRouter) Register(method, path string, _ http.Handler, _ ...wrouter.RouteParam) error {
+	f.registered = append(f.registered, recordedRoute{method: method, path: path})
+	return nil
+}
+
+func noopHandler(http.ResponseWriter, *http.Request) {}
+
+// TestResourceSubrouteRegistersFullPath confirms that a route registered several Subroute levels deep is registered
+// against the router with every ancestor's prefix joined in order.
+func TestResourceSubrouteRegistersFullPath(t *testing.T) {
+	router := &fakeRouter{}
+	root := New("test-service", router)
+	admin := root.Subroute("/admin")
+	users := admin.Subroute("/users")
+
+	if err := users.Get(context.Background(), "getUser", "/{id}", http.HandlerFunc(noopHandler)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	want := recordedRoute{method: http.MethodGet, path: "/admin/users/{id}"}
+	if len(router.registered) != 1 || router.registered[0] != want {
+		t.Fatalf("got %+v, want [%+v]", router.registered, want)
+	}
+}
+
+// TestResourceRegistrationOrder confirms that routes are handed to the router in the order Register (or its
+// shorthands) were called, interleaved across sibling and ancestor Resources exactly as the caller called them,
+// since wrouter.Router has no way to reorder or unregister a route after the fact.
+func TestResourceRegistrationOrder(t *testing.T) {
+	router := &fakeRouter{}
+	root := New("test-service", router)
+	widgets := root.Subroute("/widgets")
+	gadgets := root.Subroute("/gadgets")
+
+	mustRegister := func(r Resource, method, path string) {
+		t.Helper()
+		if err := r.Register(context.Background(), "ep", method, path, http.HandlerFunc(noopHandler)); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	mustRegister(widgets, http.MethodGet, "/")
+	mustRegister(gadgets, http.MethodGet, "/")
+	mustRegister(widgets, http.MethodPost, "/")
+	mustRegister(root, http.MethodGet, "/health")
+
+	want := []recordedRoute{
+		{method: http.MethodGet, path: "/widgets"},
+		{method: http.MethodGet, path: "/gadgets"},
+		{method: http.MethodPost, path: "/widgets"},
+		{method: http.MethodGet, path: "/health"},
+	}
+	if len(router.registered) != len(want) {
+		t.Fatalf("got %d registrations, want %d: %+v", len(router.registered), len(want), router.registered)
+	}
+	for i, route := range want {
+		if router.registered[i] != route {
+			t.Errorf("registration %d: got %+v, want %+v", i, router.registered[i], route)
+		}
+	}
+}
+
+// TestResourceSubrouteDoesNotMutateParent confirms that deriving a child Resource via Subroute or With, and then
+// registering further routes or sub-routes from that child, never changes what the parent (or a sibling derived
+// from the same parent) will register.
+func TestResourceSubrouteDoesNotMutateParent(t *testing.T) {
+	router := &fakeRouter{}
+	root := New("test-service", router)
+	parent := root.Subroute("/parent")
+
+	childA := parent.Subroute("/a")
+	childB := parent.Subroute("/b")
+
+	// Registering against childA, and deriving further from it, must not affect childB or parent.
+	_ = childA.Subroute("/nested", wrouter.Middleware(func(next http.Handler) http.Handler { return next }))
+	if err := childA.Get(context.Background(), "ep", "/", http.HandlerFunc(noopHandler)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := childB.Get(context.Background(), "ep", "/", http.HandlerFunc(noopHandler)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := parent.Get(context.Background(), "ep", "/", http.HandlerFunc(noopHandler)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	want := []recordedRoute{
+		{method: http.MethodGet, path: "/parent/a"},
+		{method: http.MethodGet, path: "/parent/b"},
+		{method: http.MethodGet, path: "/parent"},
+	}
+	if len(router.registered) != len(want) {
+		t.Fatalf("got %d registrations, want %d: %+v", len(router.registered), len(want), router.registered)
+	}
+	for i, route := range want {
+		if router.registered[i] != route {
+			t.Errorf("registration %d: got %+v, want %+v", i, router.registered[i], route)
+		}
+	}
+}
+
+// TestResourceSubrouteComposesSubResourceTag confirms that Subroute accumulates the SubResourceTagName tag value
+// (the prefix with path separators trimmed, joined by /) across multiple levels of nesting, and that With never
+// changes it.
+func TestResourceSubrouteComposesSubResourceTag(t *testing.T) {
+	root := New("test-service", &fakeRouter{}).(*resourceImpl)
+	if root.subResourceName != "" {
+		t.Fatalf("root subResourceName = %q, want empty", root.subResourceName)
+	}
+
+	admin := root.Subroute("/admin/").(*resourceImpl)
+	if admin.subResourceName != "admin" {
+		t.Fatalf("admin subResourceName = %q, want %q", admin.subResourceName, "admin")
+	}
+
+	users := admin.Subroute("users").(*resourceImpl)
+	if users.subResourceName != "admin/users" {
+		t.Fatalf("users subResourceName = %q, want %q", users.subResourceName, "admin/users")
+	}
+
+	usersWithParams := users.With(wrouter.Middleware(func(next http.Handler) http.Handler { return next })).(*resourceImpl)
+	if usersWithParams.subResourceName != users.subResourceName {
+		t.Fatalf("With changed subResourceName: got %q, want %q", usersWithParams.subResourceName, users.subResourceName)
+	}
+
+	// Deriving from admin again must not see users' prefix.
+	billing := admin.Subroute("billing").(*resourceImpl)
+	if billing.subResourceName != "admin/billing" {
+		t.Fatalf("billing subResourceName = %q, want %q", billing.subResourceName, "admin/billing")
+	}
+}
+
+// TestResourceSubrouteAndWithInheritResourceAndVersion confirms that resourceName, version, and the openAPI registry
+// pointer are carried unchanged through both Subroute and With, however deep the tree, since those identify which
+// top-level Resource a nested route belongs to.
+func TestResourceSubrouteAndWithInheritResourceAndVersion(t *testing.T) {
+	registry := NewOpenAPIRegistry()
+	root := NewWithOpenAPI("test-service", &fakeRouter{}, registry).(*resourceImpl)
+	root.version = "v2"
+
+	child := root.Subroute("/child").With().(*resourceImpl)
+	if child.resourceName != root.resourceName {
+		t.Errorf("resourceName = %q, want %q", child.resourceName, root.resourceName)
+	}
+	if child.version != root.version {
+		t.Errorf("version = %q, want %q", child.version, root.version)
+	}
+	if child.openAPI != registry {
+		t.Errorf("openAPI registry not propagated through Subroute/With")
+	}
+}
+
+// TestResourceInheritedParamsOrder confirms that RouteParams accumulate in the order they were supplied across the
+// whole ancestor chain: params from the root come first, then each Subroute/With call's own params, in the order
+// those calls were made, ending with the params passed at the final registration call site. The OpenAPI registry
+// gives an in-package, inspectable record of the param ordering via the Tag RouteParam.
+func TestResourceInheritedParamsOrder(t *testing.T) {
+	registry := NewOpenAPIRegistry()
+	root := NewWithOpenAPI("test-service", &fakeRouter{}, registry)
+	admin := root.Subroute("/admin", Tag("root-tag"))
+	users := admin.With(Tag("admin-tag")).Subroute("/users", Tag("users-tag"))
+
+	if err := users.Get(context.Background(), "getUser", "/{id}", http.HandlerFunc(noopHandler), Tag("call-site-tag")); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	doc := registry.Document("test", "1.0.0")
+	paths, _ := doc["paths"].(map[string]interface{})
+	pathItem, _ := paths["/admin/users/{id}"].(map[string]interface{})
+	op, _ := pathItem["get"].(map[string]interface{})
+	tags, _ := op["tags"].([]string)
+
+	want := []string{"test-service", "root-tag", "admin-tag", "users-tag", "call-site-tag"}
+	if len(tags) != len(want) {
+		t.Fatalf("got tags %v, want %v", tags, want)
+	}
+	for i, tag := range want {
+		if tags[i] != tag {
+			t.Errorf("tag %d: got %q, want %q", i, tags[i], tag)
+		}
+	}
+}
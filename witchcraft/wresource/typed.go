@@ -0,0 +1,285 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/palantir/pkg/metrics"
+	werror "github.com/palantir/witchcraft-go-error"
+	"github.com/palantir/witchcraft-go-server/wrouter"
+)
+
+// DefaultMaxRequestBodyBytes bounds the size of a request body that RegisterTyped will decode. Requests whose body
+// exceeds this limit fail decoding with a 400 rather than being read into memory in full.
+const DefaultMaxRequestBodyBytes = 10 << 20 // 10 MiB
+
+const (
+	// TypedHandlerLatencyMetricName is the histogram, in milliseconds, of time spent decoding the request, running
+	// the handler, and encoding the response, tagged exactly as resourceImpl.Register tags the route (service-name,
+	// endpoint, method, and, if applicable, sub-resource and version).
+	TypedHandlerLatencyMetricName = "typed-handler-latency"
+
+	// TypedHandlerRequestSizeMetricName is the histogram, in bytes, of request bodies decoded by RegisterTyped,
+	// tagged the same way as TypedHandlerLatencyMetricName.
+	TypedHandlerRequestSizeMetricName = "typed-handler-request-size"
+
+	// TypedHandlerResponseSizeMetricName is the histogram, in bytes, of response bodies written by RegisterTyped,
+	// tagged the same way as TypedHandlerLatencyMetricName.
+	TypedHandlerResponseSizeMetricName = "typed-handler-response-size"
+)
+
+// defaultAcceptedContentTypes are the request Content-Types that typedHandlerFunc will decode as JSON. A request
+// with a body and a Content-Type outside this set is rejected with a 415 before the handler ever runs.
+var defaultAcceptedContentTypes = []string{"application/json", ""}
+
+// TypedHandler handles a single JSON request of type Req and returns a JSON response of type Resp, or an error.
+type TypedHandler[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// RegisterTyped registers an endpoint on resource that JSON-decodes the request body into Req, invokes handler, and
+// JSON-encodes the returned Resp, removing the decode/encode/error-shape boilerplate that callers previously wrote
+// by hand inside an http.Handler. A request whose Content-Type isn't JSON is rejected with a 415 before decoding
+// runs, and a request whose Accept header can't be satisfied with JSON is rejected with a 406. Errors returned by
+// handler, or encountered while decoding the request or encoding the response, are surfaced to the client as a
+// structured JSON error body; they are not returned from RegisterTyped, whose own error reports only a failure to
+// register the route. The resulting route is
+// registered and tagged exactly as a Register call would be, and TypedHandlerLatencyMetricName,
+// TypedHandlerRequestSizeMetricName, and TypedHandlerResponseSizeMetricName are recorded with those same tags.
+func RegisterTyped[Req, Resp any](ctx context.Context, resource Resource, endpointName, method, path string, handler TypedHandler[Req, Resp], params ...wrouter.RouteParam) error {
+	return resource.Register(ctx, endpointName, method, path, typedHandlerFunc(handler), params...)
+}
+
+func typedHandlerFunc[Req, Resp any](handler TypedHandler[Req, Resp]) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		reg := metrics.FromContext(ctx)
+		start := time.Now()
+		defer func() {
+			reg.Histogram(TypedHandlerLatencyMetricName).Update(time.Since(start).Milliseconds())
+		}()
+
+		if !acceptsContentType(req) {
+			writeTypedError(rw, http.StatusUnsupportedMediaType, werror.WrapWithContextParams(ctx, fmt.Errorf("unsupported Content-Type %q", req.Header.Get("Content-Type")), "failed to decode request body"))
+			return
+		}
+		if !acceptsJSONResponse(req) {
+			writeTypedError(rw, http.StatusNotAcceptable, werror.WrapWithContextParams(ctx, fmt.Errorf("unsupported Accept %q", req.Header.Get("Accept")), "cannot satisfy Accept header"))
+			return
+		}
+
+		var reqBody Req
+		if req.ContentLength != 0 {
+			body := http.MaxBytesReader(rw, req.Body, DefaultMaxRequestBodyBytes)
+			counting := &countingReader{r: body}
+			if err := json.NewDecoder(counting).Decode(&reqBody); err != nil && err != io.EOF {
+				writeTypedError(rw, http.StatusBadRequest, werror.WrapWithContextParams(ctx, err, "failed to decode request body"))
+				return
+			}
+			reg.Histogram(TypedHandlerRequestSizeMetricName).Update(counting.n)
+		}
+
+		resp, err := handler(ctx, reqBody)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if _, ok := err.(*ValidationError); ok {
+				status = http.StatusBadRequest
+			}
+			writeTypedError(rw, status, err)
+			return
+		}
+
+		respBody, err := json.Marshal(resp)
+		if err != nil {
+			writeTypedError(rw, http.StatusInternalServerError, werror.WrapWithContextParams(ctx, err, "failed to encode response body"))
+			return
+		}
+		reg.Histogram(TypedHandlerResponseSizeMetricName).Update(int64(len(respBody)))
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(respBody)
+	})
+}
+
+// acceptsContentType reports whether req's Content-Type (ignoring parameters like charset) is one that
+// typedHandlerFunc knows how to decode as JSON. A request with no body (empty Content-Type) is always accepted.
+func acceptsContentType(req *http.Request) bool {
+	if req.ContentLength == 0 {
+		return true
+	}
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range defaultAcceptedContentTypes {
+		if accepted != "" && mediaType == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsJSONResponse reports whether req's Accept header (if any) permits an "application/json" response.
+func acceptsJSONResponse(req *http.Request) bool {
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/json" || mediaType == "application/*" || mediaType == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader wraps an io.Reader and tallies the number of bytes read through it, so the size of a request body
+// can be recorded without buffering it twice.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// typedErrorBody is the JSON shape written for any error returned from a RegisterTyped handler (or from decoding
+// its request). Field is set only for ValidationErrors.
+type typedErrorBody struct {
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+func writeTypedError(rw http.ResponseWriter, status int, err error) {
+	body := typedErrorBody{Message: err.Error()}
+	if verr, ok := err.(*ValidationError); ok {
+		body.Field = verr.Field
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(body)
+}
+
+// ValidationError reports that a single named field of a request (typically a path or query parameter bound via
+// BindPath or BindQuery) failed to parse or failed validation. RegisterTyped handlers can return a *ValidationError
+// from their handler func to have it surfaced to the client as a 400 with field-level detail.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// BindQuery populates the fields of dst, which must be a pointer to a struct, from req's URL query parameters.
+// Fields are matched by a `query:"name"` struct tag; fields without the tag are ignored. Supported field types are
+// string, int, int64, float64, and bool. BindQuery returns a *ValidationError naming the first field whose query
+// parameter is present but cannot be parsed as the field's type.
+func BindQuery(req *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	query := req.URL.Query()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("query")
+		if !ok {
+			continue
+		}
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		fv := v.Field(i)
+		if err := setFieldValue(fv, raw); err != nil {
+			return &ValidationError{Field: name, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// BindPath populates the fields of dst, which must be a pointer to a struct, from req's path parameters (the named
+// segments of the route's registered path, e.g. "/widgets/{id}"). Fields are matched by a `path:"name"` struct tag;
+// fields without the tag are ignored. Supported field types are string, int, int64, float64, and bool. BindPath
+// returns a *ValidationError naming the first field whose path parameter is present but cannot be parsed as the
+// field's type.
+func BindPath(req *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	pathParams := wrouter.PathParams(req)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("path")
+		if !ok {
+			continue
+		}
+		raw, ok := pathParams[name]
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if err := setFieldValue(fv, raw); err != nil {
+			return &ValidationError{Field: name, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+func setFieldValue(fv reflect.Value, raw string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", raw)
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", raw)
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %q", raw)
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type echoReq struct {
+	Name string `json:"name"`
+}
+
+type echoResp struct {
+	Greeting string  `json:"greeting"`
+	Score    float64 `json:"score,omitempty"`
+}
+
+func TestTypedHandlerFuncRoundTrip(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{Greeting: "hello " + req.Name}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp echoResp
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response body %q: %v", rec.Body.String(), err)
+	}
+	if resp.Greeting != "hello alice" {
+		t.Fatalf("got greeting %q, want %q", resp.Greeting, "hello alice")
+	}
+}
+
+func TestTypedHandlerFuncRejectsUnsupportedContentType(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("Content-Type", "application/xml")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("got status %d, want 415", rec.Code)
+	}
+}
+
+func TestTypedHandlerFuncRejectsUnsatisfiableAccept(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("got status %d, want 406", rec.Code)
+	}
+}
+
+func TestTypedHandlerFuncRejectsMalformedBody(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{not json`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400", rec.Code)
+	}
+}
+
+func TestTypedHandlerFuncValidationError(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{}, &ValidationError{Field: "name", Message: "must not be empty"}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want 400", rec.Code)
+	}
+	var body typedErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode error body %q: %v", rec.Body.String(), err)
+	}
+	if body.Field != "name" {
+		t.Errorf("got field %q, want %q", body.Field, "name")
+	}
+}
+
+func TestTypedHandlerFuncHandlerError(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{}, errTypedHandlerBoom
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want 500", rec.Code)
+	}
+}
+
+// TestTypedHandlerFuncMarshalErrorSurfacesAsStructuredError is a regression test for a bug where a response that
+// failed to json.Marshal (e.g. a float64 holding NaN, which encoding/json refuses to encode) left the handler
+// returning a 200 with an empty body instead of surfacing the encoding failure to the client.
+func TestTypedHandlerFuncMarshalErrorSurfacesAsStructuredError(t *testing.T) {
+	handler := typedHandlerFunc(func(ctx context.Context, req echoReq) (echoResp, error) {
+		return echoResp{Score: math.NaN()}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want 500", rec.Code)
+	}
+	if rec.Body.Len() == 0 {
+		t.Fatal("got empty body, want a structured JSON error")
+	}
+	var body typedErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q is not valid JSON: %v", rec.Body.String(), err)
+	}
+	if body.Message == "" {
+		t.Error("got empty error message")
+	}
+}
+
+var errTypedHandlerBoom = &testError{"boom"}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
+
+func TestBindQuery(t *testing.T) {
+	type dst struct {
+		Name   string `query:"name"`
+		Limit  int    `query:"limit"`
+		Hidden string
+	}
+	req := httptest.NewRequest(http.MethodGet, "/?name=alice&limit=10", nil)
+
+	var d dst
+	if err := BindQuery(req, &d); err != nil {
+		t.Fatalf("BindQuery: %v", err)
+	}
+	if d.Name != "alice" || d.Limit != 10 {
+		t.Fatalf("got %+v", d)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?limit=notanumber", nil)
+	if err := BindQuery(req, &d); err == nil {
+		t.Fatal("expected an error for a non-numeric limit")
+	} else if verr, ok := err.(*ValidationError); !ok || verr.Field != "limit" {
+		t.Fatalf("got error %v, want a *ValidationError for field \"limit\"", err)
+	}
+}
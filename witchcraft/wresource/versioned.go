@@ -0,0 +1,244 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/palantir/pkg/metrics"
+	werror "github.com/palantir/witchcraft-go-error"
+	"github.com/palantir/witchcraft-go-server/wrouter"
+)
+
+const (
+	// DeprecationHeaderName and SunsetHeaderName are the response headers set on every call to a version marked
+	// Deprecated, per RFC 8594.
+	DeprecationHeaderName = "Deprecation"
+	SunsetHeaderName      = "Sunset"
+
+	// DeprecatedEndpointCallsMetricName is the counter incremented once per call to a version marked Deprecated,
+	// tagged with ResourceTagName, EndpointTagName, and VersionTagName.
+	DeprecatedEndpointCallsMetricName = "deprecated-endpoint-calls"
+)
+
+// VersionedResource fans every registration out across a fixed set of API versions, each mounted under its own
+// "/api/{version}" path prefix, and tags every recorded metric with the VersionTagName of the version it was
+// registered for. Versions can be independently marked Deprecated or Retired so that operators can quantify and
+// drive migration off old versions without touching callers who have already moved to the replacement.
+type VersionedResource interface {
+	// Register fans out to every version, including Retired ones: performs Register(ctx, endpointName, method,
+	// path, handler, params...) against each version's Resource, with its path prefixed by that version. Routes of a
+	// Retired version answer with 410 Gone instead of running handler.
+	Register(ctx context.Context, endpointName, method, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Get is a shorthand for Register(endpointName, http.MethodGet, handler, params...)
+	Get(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Head is a shorthand for Register(endpointName, http.MethodHead, handler, params...)
+	Head(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Post is a shorthand for Register(endpointName, http.MethodPost, handler, params...)
+	Post(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Put is a shorthand for Register(endpointName, http.MethodPut, handler, params...)
+	Put(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Patch is a shorthand for Register(endpointName, http.MethodPatch, handler, params...)
+	Patch(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Delete is a shorthand for Register(endpointName, http.MethodDelete, handler, params...)
+	Delete(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error
+
+	// Deprecate marks version as deprecated. Every response served from that version, whether registered before or
+	// after this call, gains a Deprecation header and, if sunset is non-zero, a Sunset header (RFC 8594), and
+	// increments DeprecatedEndpointCallsMetricName: the deprecated state is consulted per-request, not baked in at
+	// Register time. Returns an error if version was not one of the versions passed to NewVersioned.
+	Deprecate(version string, sunset time.Time) error
+
+	// Retire marks version as retired. Every request served from that version, whether registered before or after
+	// this call, gets a 410 Gone response with a structured body naming replacementVersion instead of running the
+	// registered handler: the retired state is consulted per-request, not baked in at Register time. Returns an
+	// error if version was not one of the versions passed to NewVersioned.
+	Retire(version, replacementVersion string) error
+}
+
+// NewVersioned returns a VersionedResource that fans every registration out across versions, each mounted at
+// "/api/{version}" (e.g. version "v1" mounts a route registered at "/widgets" as "/api/v1/widgets").
+func NewVersioned(resourceName string, versions []string, router wrouter.Router) VersionedResource {
+	v := &versionedResourceImpl{
+		resourceName: resourceName,
+		entries:      make(map[string]*versionEntry, len(versions)),
+	}
+	for _, version := range versions {
+		v.order = append(v.order, version)
+		v.entries[version] = &versionEntry{
+			resource: &resourceImpl{
+				resourceName: resourceName,
+				router:       router,
+				pathPrefix:   strings.Replace(versionedPathTemplate, versionPlaceholder, version, 1),
+				version:      version,
+			},
+		}
+	}
+	return v
+}
+
+const (
+	versionedPathTemplate = "/api/{version}"
+	versionPlaceholder    = "{version}"
+)
+
+type versionEntry struct {
+	resource *resourceImpl
+
+	deprecated bool
+	sunset     time.Time
+
+	retired            bool
+	replacementVersion string
+}
+
+type versionedResourceImpl struct {
+	resourceName string
+
+	mu      sync.RWMutex
+	entries map[string]*versionEntry
+	order   []string
+}
+
+func (v *versionedResourceImpl) Register(ctx context.Context, endpointName, method, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, version := range v.order {
+		entry := v.entries[version]
+		versionParams := append(append([]wrouter.RouteParam{}, params...), versionStateParam(v, entry))
+
+		if err := entry.resource.Register(ctx, endpointName, method, path, handler, versionParams...); err != nil {
+			return werror.WrapWithContextParams(ctx, err, "failed to register versioned route", werror.SafeParam("version", version))
+		}
+	}
+	return nil
+}
+
+func (v *versionedResourceImpl) Get(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	return v.Register(ctx, endpointName, http.MethodGet, path, handler, params...)
+}
+
+func (v *versionedResourceImpl) Head(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	return v.Register(ctx, endpointName, http.MethodHead, path, handler, params...)
+}
+
+func (v *versionedResourceImpl) Post(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	return v.Register(ctx, endpointName, http.MethodPost, path, handler, params...)
+}
+
+func (v *versionedResourceImpl) Put(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	return v.Register(ctx, endpointName, http.MethodPut, path, handler, params...)
+}
+
+func (v *versionedResourceImpl) Patch(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	return v.Register(ctx, endpointName, http.MethodPatch, path, handler, params...)
+}
+
+func (v *versionedResourceImpl) Delete(ctx context.Context, endpointName, path string, handler http.Handler, params ...wrouter.RouteParam) error {
+	return v.Register(ctx, endpointName, http.MethodDelete, path, handler, params...)
+}
+
+func (v *versionedResourceImpl) Deprecate(version string, sunset time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.entries[version]
+	if !ok {
+		return fmt.Errorf("wresource: %q is not a version of resource %q", version, v.resourceName)
+	}
+	entry.deprecated = true
+	entry.sunset = sunset
+	return nil
+}
+
+func (v *versionedResourceImpl) Retire(version, replacementVersion string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.entries[version]
+	if !ok {
+		return fmt.Errorf("wresource: %q is not a version of resource %q", version, v.resourceName)
+	}
+	entry.retired = true
+	entry.replacementVersion = replacementVersion
+	return nil
+}
+
+// versionStateParam returns a RouteParam that, on every request, checks entry's current retired and deprecated state
+// (which Retire and Deprecate can flip at any time, long after this param's route was registered) rather than state
+// baked in at Register time. It just wraps versionStateMiddleware, which is kept as a plain
+// func(http.Handler) http.Handler so tests in this package can exercise it directly against an
+// httptest.ResponseRecorder without depending on wrouter.
+func versionStateParam(v *versionedResourceImpl, entry *versionEntry) wrouter.RouteParam {
+	return wrouter.Middleware(versionStateMiddleware(v, entry))
+}
+
+// versionStateMiddleware checks entry's current retired and deprecated state on every request. A retired entry gets
+// the 410 Gone retired response instead of running next; a deprecated, non-retired entry gets the Deprecation/Sunset
+// response headers (RFC 8594) and an increment of DeprecatedEndpointCallsMetricName, tagged with whatever tags
+// resourceImpl.Register already attached to the request context (service-name, endpoint, and version), before
+// running next.
+func versionStateMiddleware(v *versionedResourceImpl, entry *versionEntry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			v.mu.RLock()
+			retired, replacementVersion := entry.retired, entry.replacementVersion
+			deprecated, sunset := entry.deprecated, entry.sunset
+			v.mu.RUnlock()
+
+			if retired {
+				retiredVersionHandler(replacementVersion).ServeHTTP(rw, req)
+				return
+			}
+			if deprecated {
+				rw.Header().Set(DeprecationHeaderName, "true")
+				if !sunset.IsZero() {
+					rw.Header().Set(SunsetHeaderName, sunset.UTC().Format(http.TimeFormat))
+				}
+				metrics.FromContext(req.Context()).Counter(DeprecatedEndpointCallsMetricName).Inc(1)
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+type retiredVersionErrorBody struct {
+	Message            string `json:"message"`
+	ReplacementVersion string `json:"replacementVersion,omitempty"`
+}
+
+func retiredVersionHandler(replacementVersion string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusGone)
+		_ = json.NewEncoder(rw).Encode(retiredVersionErrorBody{
+			Message:            "this API version has been retired",
+			ReplacementVersion: replacementVersion,
+		})
+	})
+}
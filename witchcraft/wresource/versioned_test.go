@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wresource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func okHandlerVersioned() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+// TestVersionStateMiddlewareRetireIsRetroactive is a regression test for the bug where Retire only affected routes
+// registered after it was called, because the retired/not-retired handler was chosen once at Register time. Since
+// versionStateMiddleware re-reads entry's state on every request, the same handler value must start serving 410
+// once Retire is called, with no re-registration required.
+func TestVersionStateMiddlewareRetireIsRetroactive(t *testing.T) {
+	v := &versionedResourceImpl{entries: map[string]*versionEntry{}}
+	entry := &versionEntry{}
+	v.entries["v1"] = entry
+	handler := versionStateMiddleware(v, entry)(okHandlerVersioned())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("before Retire: got status %d, want 200", rec.Code)
+	}
+
+	if err := v.Retire("v1", "v2"); err != nil {
+		t.Fatalf("Retire: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	if rec.Code != http.StatusGone {
+		t.Fatalf("after Retire: got status %d, want 410", rec.Code)
+	}
+	var body retiredVersionErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q is not valid JSON: %v", rec.Body.String(), err)
+	}
+	if body.ReplacementVersion != "v2" {
+		t.Fatalf("got replacementVersion %q, want %q", body.ReplacementVersion, "v2")
+	}
+}
+
+func TestVersionStateMiddlewareDeprecateIsRetroactive(t *testing.T) {
+	v := &versionedResourceImpl{entries: map[string]*versionEntry{}}
+	entry := &versionEntry{}
+	v.entries["v1"] = entry
+	handler := versionStateMiddleware(v, entry)(okHandlerVersioned())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	if rec.Header().Get(DeprecationHeaderName) != "" {
+		t.Fatalf("before Deprecate: got Deprecation header %q, want none", rec.Header().Get(DeprecationHeaderName))
+	}
+
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := v.Deprecate("v1", sunset); err != nil {
+		t.Fatalf("Deprecate: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("after Deprecate: got status %d, want 200", rec.Code)
+	}
+	if rec.Header().Get(DeprecationHeaderName) != "true" {
+		t.Fatalf("after Deprecate: got Deprecation header %q, want %q", rec.Header().Get(DeprecationHeaderName), "true")
+	}
+	if got, want := rec.Header().Get(SunsetHeaderName), sunset.UTC().Format(http.TimeFormat); got != want {
+		t.Fatalf("after Deprecate: got Sunset header %q, want %q", got, want)
+	}
+}
+
+func TestVersionStateMiddlewareRetireTakesPrecedenceOverDeprecate(t *testing.T) {
+	v := &versionedResourceImpl{entries: map[string]*versionEntry{}}
+	entry := &versionEntry{}
+	v.entries["v1"] = entry
+	handler := versionStateMiddleware(v, entry)(okHandlerVersioned())
+
+	if err := v.Deprecate("v1", time.Time{}); err != nil {
+		t.Fatalf("Deprecate: %v", err)
+	}
+	if err := v.Retire("v1", "v2"); err != nil {
+		t.Fatalf("Retire: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	if rec.Code != http.StatusGone {
+		t.Fatalf("got status %d, want 410", rec.Code)
+	}
+}
+
+func TestVersionedResourceDeprecateAndRetireErrorOnUnknownVersion(t *testing.T) {
+	v := &versionedResourceImpl{entries: map[string]*versionEntry{"v1": {}}}
+
+	if err := v.Deprecate("v2", time.Time{}); err == nil {
+		t.Fatal("expected an error deprecating an unknown version")
+	}
+	if err := v.Retire("v2", "v3"); err == nil {
+		t.Fatal("expected an error retiring an unknown version")
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wmiddleware provides a curated set of wrouter.RouteParam middlewares for cross-cutting HTTP concerns,
+// modeled on the middlewares that chi's middleware package provides. Each middleware is a plain
+// func(http.Handler) http.Handler wrapped as a wrouter.RouteParam, so it can be passed alongside any other
+// wrouter.RouteParam to Resource.Get/Post/... (or wresource.Resource.With, to apply it to a whole group of routes).
+// Each exported constructor just wraps an unexported func(http.Handler) http.Handler, so tests in this package can
+// exercise that middleware func directly against an httptest.ResponseRecorder without depending on wrouter.
+// Middlewares that emit metrics use metrics.FromContext, which picks up the service-name/method/endpoint tags that
+// resourceImpl.Register already attaches to the request context, so operators get per-endpoint observability for
+// free.
+package wmiddleware
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/palantir/pkg/metrics"
+	"github.com/palantir/witchcraft-go-server/wrouter"
+)
+
+// Heartbeat returns a RouteParam that responds to GET and HEAD requests for the given path with a cheap "."
+// response, short-circuiting the rest of the handler chain. It is intended for use as a liveness probe endpoint
+// that should stay up even if the rest of the service's dependencies are degraded.
+func Heartbeat(heartbeatPath string) wrouter.RouteParam {
+	return wrouter.Middleware(heartbeatMiddleware(heartbeatPath))
+}
+
+func heartbeatMiddleware(heartbeatPath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.URL.Path == heartbeatPath {
+				rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				rw.WriteHeader(http.StatusOK)
+				_, _ = rw.Write([]byte("."))
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// GetHead returns a RouteParam for use on a route registered with Resource.Head using the same handler as the
+// corresponding GET route (e.g. resource.Head(ctx, name, path, getHandler, wmiddleware.GetHead())): it rewrites the
+// inbound HEAD request to look like a GET before invoking that handler, so handler logic that branches on method
+// runs its GET path, and discards whatever body the handler writes so only headers reach the client. wrouter.Router
+// dispatches on an exact (method, path) pair, so GetHead does not make the router invoke a GET handler for a HEAD
+// request that was never registered; the caller must still register the HEAD route explicitly.
+func GetHead() wrouter.RouteParam {
+	return wrouter.Middleware(getHeadMiddleware)
+}
+
+func getHeadMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodHead {
+			req = req.Clone(req.Context())
+			req.Method = http.MethodGet
+			next.ServeHTTP(&headResponseWriter{ResponseWriter: rw}, req)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// NoCache returns a RouteParam that sets response headers instructing clients and intermediate caches not to cache
+// the response.
+func NoCache() wrouter.RouteParam {
+	return wrouter.Middleware(noCacheMiddleware)
+}
+
+func noCacheMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Cache-Control", "no-cache, private, max-age=0")
+		rw.Header().Set("Pragma", "no-cache")
+		rw.Header().Set("Expires", "0")
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// contentCharsetRejectedMetricName is the counter incremented each time ContentCharset rejects a request. It is
+// tagged with whatever tags are present on the request context, which includes the service-name/method/endpoint
+// tags that resourceImpl.Register attaches.
+const contentCharsetRejectedMetricName = "wresource.content_charset.rejected"
+
+// ContentCharset returns a RouteParam that rejects requests (with a 415) whose Content-Type charset parameter is
+// set but does not match one of the provided charsets, incrementing contentCharsetRejectedMetricName. Requests with
+// no charset parameter are allowed through.
+func ContentCharset(charsets ...string) wrouter.RouteParam {
+	return wrouter.Middleware(contentCharsetMiddleware(charsets))
+}
+
+func contentCharsetMiddleware(charsets []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if contentType := req.Header.Get("Content-Type"); contentType != "" {
+				if _, params, err := mime.ParseMediaType(contentType); err == nil {
+					if charset := params["charset"]; charset != "" && !containsFold(charsets, charset) {
+						metrics.FromContext(req.Context()).Counter(contentCharsetRejectedMetricName).Inc(1)
+						http.Error(rw, "unsupported charset", http.StatusUnsupportedMediaType)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// allowContentTypeRejectedMetricName is the counter incremented each time AllowContentType rejects a request. It is
+// tagged with whatever tags are present on the request context, which includes the service-name/method/endpoint
+// tags that resourceImpl.Register attaches.
+const allowContentTypeRejectedMetricName = "wresource.allow_content_type.rejected"
+
+// AllowContentType returns a RouteParam that rejects requests (with a 415) whose Content-Type does not match one
+// of the provided content types, incrementing allowContentTypeRejectedMetricName. Requests with no body (indicated
+// by a zero Content-Length) are allowed through.
+func AllowContentType(contentTypes ...string) wrouter.RouteParam {
+	return wrouter.Middleware(allowContentTypeMiddleware(contentTypes))
+}
+
+func allowContentTypeMiddleware(contentTypes []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.ContentLength == 0 {
+				next.ServeHTTP(rw, req)
+				return
+			}
+			contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil || !containsFold(contentTypes, contentType) {
+				metrics.FromContext(req.Context()).Counter(allowContentTypeRejectedMetricName).Inc(1)
+				http.Error(rw, "unsupported content type", http.StatusUnsupportedMediaType)
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+func containsFold(vals []string, val string) bool {
+	for _, v := range vals {
+		if strings.EqualFold(v, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// timeoutExpiredMetricName is the counter incremented each time a Timeout-wrapped handler is still running when d
+// elapses. It is tagged with whatever tags are present on the request context, which includes the
+// service-name/method/endpoint tags that resourceImpl.Register attaches.
+const timeoutExpiredMetricName = "wresource.timeout.expired"
+
+// Timeout returns a RouteParam that cancels the request context once d has elapsed. Handlers that honor context
+// cancellation will abort their work, but Timeout does not itself write a response: the handler is responsible for
+// checking ctx.Err() and returning an appropriate error. timeoutExpiredMetricName is incremented whenever the
+// handler is still running once d elapses.
+func Timeout(d time.Duration) wrouter.RouteParam {
+	return wrouter.Middleware(timeoutMiddleware(d))
+}
+
+func timeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			next.ServeHTTP(rw, req.WithContext(ctx))
+			if ctx.Err() == context.DeadlineExceeded {
+				metrics.FromContext(req.Context()).Counter(timeoutExpiredMetricName).Inc(1)
+			}
+		})
+	}
+}
+
+// throttleRejectedMetricName is the counter incremented each time Throttle rejects a request because the endpoint
+// already has limit requests in flight and queueSize requests already queued waiting for a slot. It is tagged with
+// whatever tags are present on the request context, which includes the service-name/method/endpoint tags that
+// resourceImpl.Register attaches.
+const throttleRejectedMetricName = "wresource.throttle.rejected"
+
+// Throttle returns a RouteParam that bounds the number of in-flight requests for the endpoint it is applied to at
+// limit. Once limit requests are in flight, up to queueSize additional requests are admitted and held waiting for a
+// slot to free up rather than being rejected outright; a request that arrives once limit+queueSize requests are
+// already in flight or waiting is rejected immediately with a 503, incrementing the throttleRejectedMetricName
+// counter. Pass queueSize 0 to reject outright as soon as limit requests are in flight, with no waiting. Each call
+// to Throttle creates one bound, which is shared by every route the returned RouteParam is passed to (for example,
+// every route of a Resource produced by With).
+func Throttle(limit, queueSize int) wrouter.RouteParam {
+	if limit <= 0 {
+		panic("wmiddleware: Throttle limit must be positive, got " + strconv.Itoa(limit))
+	}
+	if queueSize < 0 {
+		panic("wmiddleware: Throttle queueSize must not be negative, got " + strconv.Itoa(queueSize))
+	}
+	return wrouter.Middleware(throttleMiddleware(limit, queueSize))
+}
+
+func throttleMiddleware(limit, queueSize int) func(http.Handler) http.Handler {
+	admitted := make(chan struct{}, limit+queueSize)
+	inFlight := make(chan struct{}, limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			select {
+			case admitted <- struct{}{}:
+			default:
+				metrics.FromContext(req.Context()).Counter(throttleRejectedMetricName).Inc(1)
+				http.Error(rw, "too many in-flight and queued requests for this endpoint", http.StatusServiceUnavailable)
+				return
+			}
+			defer func() { <-admitted }()
+
+			inFlight <- struct{}{}
+			defer func() { <-inFlight }()
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
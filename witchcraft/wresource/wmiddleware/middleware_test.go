@@ -0,0 +1,231 @@
+// Copyright (c) 2018 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func okHandler(body string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(body))
+	})
+}
+
+func TestHeartbeat(t *testing.T) {
+	handler := heartbeatMiddleware("/healthz")(okHandler("from next"))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "." {
+		t.Fatalf("got status=%d body=%q, want 200 \".\"", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rec.Body.String() != "from next" {
+		t.Fatalf("non-heartbeat path did not reach next handler: got body %q", rec.Body.String())
+	}
+}
+
+func TestGetHead(t *testing.T) {
+	var sawMethod string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		sawMethod = req.Method
+		_, _ = rw.Write([]byte("body"))
+	})
+	handler := getHeadMiddleware(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/widgets", nil))
+	if sawMethod != http.MethodGet {
+		t.Fatalf("handler saw method %q, want GET", sawMethod)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("HEAD response body = %q, want empty", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	if sawMethod != http.MethodGet || rec.Body.String() != "body" {
+		t.Fatalf("GET request was not passed through unchanged: method=%q body=%q", sawMethod, rec.Body.String())
+	}
+}
+
+func TestNoCache(t *testing.T) {
+	handler := noCacheMiddleware(okHandler(""))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get("Cache-Control"); got != "no-cache, private, max-age=0" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	if got := rec.Header().Get("Pragma"); got != "no-cache" {
+		t.Errorf("Pragma = %q", got)
+	}
+	if got := rec.Header().Get("Expires"); got != "0" {
+		t.Errorf("Expires = %q", got)
+	}
+}
+
+func TestContentCharset(t *testing.T) {
+	handler := contentCharsetMiddleware([]string{"utf-8"})(okHandler(""))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json; charset=utf-16")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("mismatched charset: got status %d, want 415", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("matching charset: got status %d, want 200", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("no Content-Type: got status %d, want 200", rec.Code)
+	}
+}
+
+func TestAllowContentType(t *testing.T) {
+	handler := allowContentTypeMiddleware([]string{"application/json"})(okHandler(""))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	req.ContentLength = 4
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("disallowed content type: got status %d, want 415", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = 4
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("allowed content type: got status %d, want 200", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.ContentLength = 0
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("empty body: got status %d, want 200", rec.Code)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	})
+	handler := timeoutMiddleware(10 * time.Millisecond)(slow)
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("handler did not return promptly after the deadline: took %s", elapsed)
+	}
+}
+
+// TestThrottleNoQueueCapsConcurrency is a regression test for the bug where Throttle(limit, 0) rejected every
+// request, including the very first uncontended one, because the queue slot was acquired via a non-blocking send on
+// an unbuffered channel (which never succeeds without a waiting receiver).
+func TestThrottleNoQueueCapsConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+		rw.WriteHeader(http.StatusOK)
+	})
+	handler := throttleMiddleware(1, 0)(blocking)
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+
+	// Give the first request time to acquire its slot before asserting the second is rejected.
+	time.Sleep(20 * time.Millisecond)
+
+	rec2 := httptest.NewRecorder()
+	handler.ServeHTTP(rec2, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec2.Code != http.StatusServiceUnavailable {
+		t.Fatalf("second concurrent request: got status %d, want 503", rec2.Code)
+	}
+
+	close(release)
+	<-done
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request: got status %d, want 200", rec.Code)
+	}
+}
+
+// TestThrottleQueueAdmitsWaitingRequests confirms that with a non-zero queueSize, a request that arrives while
+// limit requests are already in flight is held rather than rejected, and runs once a slot frees up.
+func TestThrottleQueueAdmitsWaitingRequests(t *testing.T) {
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+		rw.WriteHeader(http.StatusOK)
+	})
+	handler := throttleMiddleware(1, 1)(blocking)
+
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 2)
+	for i := range recs {
+		i := i
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(recs[i], httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// A third, over-limit-and-queue request must be rejected immediately.
+	rec3 := httptest.NewRecorder()
+	handler.ServeHTTP(rec3, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec3.Code != http.StatusServiceUnavailable {
+		t.Fatalf("request beyond limit+queueSize: got status %d, want 503", rec3.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Errorf("queued request %d: got status %d, want 200", i, rec.Code)
+		}
+	}
+}